@@ -11,33 +11,48 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 type Client struct {
 	text *textproto.Conn
+
+	mutex    sync.Mutex // guards text and cfg across redials
+	cfg      Config
+	pingQuit chan bool
+
+	// cmdMu serializes command issuance on the wire: roundTrip holds it
+	// for a single command's round trip, and a CommandList holds it for
+	// its entire Begin...End span, so no other command (e.g. the
+	// keepalive pingLoop) can get spliced into the middle of a command
+	// list's body.
+	cmdMu sync.Mutex
 }
 
 type Attrs map[string]string
 
 // Dial connects to MPD listening on address addr (e.g. "127.0.0.1:6600")
-// on network network (e.g. "tcp").
+// on network network (e.g. "tcp"). It is equivalent to DialWithConfig
+// with just Network and Address set.
 func Dial(network, addr string) (c *Client, err os.Error) {
-	text, err := textproto.Dial(network, addr)
-	if err != nil {
-		return nil, err
-	}
-	line, err := text.ReadLine()
-	if err != nil {
-		return nil, err
-	}
-	if line[0:6] != "OK MPD" {
-		return nil, textproto.ProtocolError("no greeting")
-	}
-	return &Client{text: text}, nil
+	return DialWithConfig(Config{Network: network, Address: addr})
+}
+
+// DialAuthenticated is like Dial, but also authenticates with MPD using
+// password, as set by MPD's "password" configuration option. It
+// returns an *AuthError if MPD rejects the password.
+func DialAuthenticated(network, addr, password string) (c *Client, err os.Error) {
+	return DialWithConfig(Config{Network: network, Address: addr, Password: password})
 }
 
 // Close terminates the connection with MPD.
 func (c *Client) Close() (err os.Error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.pingQuit != nil {
+		close(c.pingQuit)
+		c.pingQuit = nil
+	}
 	if c.text != nil {
 		c.text.PrintfLine("close")
 		err = c.text.Close()
@@ -51,37 +66,49 @@ func (c *Client) Ping() os.Error {
 	return c.okCmd("ping")
 }
 
-func (c *Client) readPlaylist() (pls []Attrs, err os.Error) {
-	pls = []Attrs{}
+func readPlaylist(text *textproto.Conn) ([]Attrs, os.Error) {
+	return readAttrsList(text, "file")
+}
+
+// readAttrsList reads a list of Attrs terminated by a line containing
+// "OK", as returned by commands like playlistinfo, listallinfo, lsinfo,
+// find and search. A new Attrs entry begins whenever a line's key
+// matches one of startKeys, which lets callers like LsInfo treat more
+// than one key (file, directory, playlist) as a record boundary.
+func readAttrsList(text *textproto.Conn, startKeys ...string) (attrs []Attrs, err os.Error) {
+	attrs = []Attrs{}
 
 	for {
-		line, err := c.text.ReadLine()
+		line, err := text.ReadLine()
 		if err != nil {
 			return nil, err
 		}
 		if line == "OK" {
 			break
 		}
-		if strings.HasPrefix(line, "file:") { // new song entry begins
-			pls = append(pls, Attrs{})
-		}
-		if len(pls) == 0 {
-			return nil, textproto.ProtocolError("unexpected: " + line)
-		}
 		z := strings.Index(line, ": ")
 		if z < 0 {
 			return nil, textproto.ProtocolError("can't parse line: " + line)
 		}
 		key := line[0:z]
-		pls[len(pls)-1][key] = line[z+2:]
+		for _, startKey := range startKeys {
+			if key == startKey { // new entry begins
+				attrs = append(attrs, Attrs{})
+				break
+			}
+		}
+		if len(attrs) == 0 {
+			return nil, textproto.ProtocolError("unexpected: " + line)
+		}
+		attrs[len(attrs)-1][key] = line[z+2:]
 	}
-	return pls, nil
+	return attrs, nil
 }
 
-func (c *Client) readAttrs() (attrs Attrs, err os.Error) {
+func readAttrs(text *textproto.Conn) (attrs Attrs, err os.Error) {
 	attrs = make(Attrs)
 	for {
-		line, err := c.text.ReadLine()
+		line, err := text.ReadLine()
 		if err != nil {
 			return nil, err
 		}
@@ -100,45 +127,45 @@ func (c *Client) readAttrs() (attrs Attrs, err os.Error) {
 
 // CurrentSong returns information about the current song in the playlist.
 func (c *Client) CurrentSong() (Attrs, os.Error) {
-	id, err := c.text.Cmd("currentsong")
+	var attrs Attrs
+	err := c.roundTrip(func(text *textproto.Conn) (err os.Error) {
+		attrs, err = readAttrs(text)
+		return
+	}, "currentsong")
+	err, reconnected := splitReconnected(err)
 	if err != nil {
 		return nil, err
 	}
-	c.text.StartResponse(id)
-	defer c.text.EndResponse(id)
-	return c.readAttrs()
+	return reconnectedAttrs(attrs, reconnected)
 }
 
 // Status returns information about the current status of MPD.
 func (c *Client) Status() (Attrs, os.Error) {
-	id, err := c.text.Cmd("status")
+	var attrs Attrs
+	err := c.roundTrip(func(text *textproto.Conn) (err os.Error) {
+		attrs, err = readAttrs(text)
+		return
+	}, "status")
+	err, reconnected := splitReconnected(err)
 	if err != nil {
 		return nil, err
 	}
-	c.text.StartResponse(id)
-	defer c.text.EndResponse(id)
-	return c.readAttrs()
+	return reconnectedAttrs(attrs, reconnected)
 }
 
-func (c *Client) readOKLine() (err os.Error) {
-	line, err := c.text.ReadLine()
+func readOKLine(text *textproto.Conn) os.Error {
+	line, err := text.ReadLine()
 	if err != nil {
-		return
+		return err
 	}
 	if line == "OK" {
 		return nil
 	}
-	return textproto.ProtocolError("unexpected response: " + line)
+	return parseServerError(line)
 }
 
 func (c *Client) okCmd(format string, args ...interface{}) os.Error {
-	id, err := c.text.Cmd(format, args...)
-	if err != nil {
-		return err
-	}
-	c.text.StartResponse(id)
-	defer c.text.EndResponse(id)
-	return c.readOKLine()
+	return c.roundTrip(readOKLine, format, args...)
 }
 
 //
@@ -210,26 +237,28 @@ func (c *Client) PlaylistInfo(start, end int) (pls []Attrs, err os.Error) {
 	if start < 0 && end >= 0 {
 		return nil, os.NewError("negative start index")
 	}
+
+	var cmdErr os.Error
 	if start >= 0 && end < 0 {
-		id, err := c.text.Cmd("playlistinfo %d", start)
-		if err != nil {
-			return nil, err
-		}
-		c.text.StartResponse(id)
-		defer c.text.EndResponse(id)
-		return c.readPlaylist()
+		cmdErr = c.roundTrip(func(text *textproto.Conn) (err os.Error) {
+			pls, err = readPlaylist(text)
+			return
+		}, "playlistinfo %d", start)
+	} else {
+		cmdErr = c.roundTrip(func(text *textproto.Conn) (err os.Error) {
+			pls, err = readPlaylist(text)
+			return
+		}, "playlistinfo")
 	}
-	id, err := c.text.Cmd("playlistinfo")
-	if err != nil {
-		return nil, err
+
+	cmdErr, reconnected := splitReconnected(cmdErr)
+	if cmdErr != nil {
+		return nil, cmdErr
 	}
-	c.text.StartResponse(id)
-	defer c.text.EndResponse(id)
-	pls, err = c.readPlaylist()
-	if err != nil || start < 0 || end < 0 {
-		return
+	if start >= 0 && end >= 0 {
+		pls = pls[start:end]
 	}
-	return pls[start:end], nil
+	return reconnectedAttrsList(pls, reconnected)
 }
 
 // Delete deletes songs from playlist. If both start and end are positive,
@@ -259,28 +288,32 @@ func (c *Client) Add(uri string) os.Error {
 // id of the song added. If pos is positive, the song is added to position
 // pos.
 func (c *Client) AddId(uri string, pos int) (int, os.Error) {
-	var id uint
+	var attrs Attrs
+	read := func(text *textproto.Conn) (err os.Error) {
+		attrs, err = readAttrs(text)
+		return
+	}
+
 	var err os.Error
 	if pos >= 0 {
-		id, err = c.text.Cmd("addid %q %d", uri, pos)
+		err = c.roundTrip(read, "addid %q %d", uri, pos)
+	} else {
+		err = c.roundTrip(read, "addid %q", uri)
 	}
-	id, err = c.text.Cmd("addid %q", uri)
+	err, reconnected := splitReconnected(err)
 	if err != nil {
 		return -1, err
 	}
 
-	c.text.StartResponse(id)
-	defer c.text.EndResponse(id)
-
-	attrs, err := c.readAttrs()
-	if err != nil {
-		return -1, err
-	}
 	tok, ok := attrs["Id"]
 	if !ok {
 		return -1, textproto.ProtocolError("addid did not return Id")
 	}
-	return strconv.Atoi(tok)
+	id, err := strconv.Atoi(tok)
+	if err != nil {
+		return -1, err
+	}
+	return reconnectedInt(id, reconnected)
 }
 
 // Clear clears the current playlist.
@@ -0,0 +1,170 @@
+// Copyright 2009 The GoMPD Authors. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package mpd
+
+import (
+	"fmt"
+	"net/textproto"
+	"os"
+	"strings"
+)
+
+// CommandList batches a sequence of commands so that they're sent to
+// MPD and executed as one, amortizing the round trip cost of issuing
+// many small commands (e.g. adding every song of an album) one at a
+// time. Create one with Client's BeginCommandList or
+// BeginCommandListOK, queue commands on it, then call End.
+type CommandList struct {
+	client *Client         // whose cmdMu is held for the list's whole Begin...End span
+	text   *textproto.Conn // the connection the list was begun on
+	id     uint
+	ok     bool // true if command_list_ok_begin was used
+}
+
+// BeginCommandList starts a command list on c. The commands queued on
+// the returned CommandList are executed by MPD as a single unit once
+// End is called; End returns only the aggregate OK/ACK, not per-command
+// results.
+func (c *Client) BeginCommandList() (*CommandList, os.Error) {
+	return c.beginCommandList("command_list_begin", false)
+}
+
+// BeginCommandListOK is like BeginCommandList, except each queued
+// command's result (list_OK) is reported separately, letting End return
+// per-command attributes and identify exactly which command in the list
+// failed.
+func (c *Client) BeginCommandListOK() (*CommandList, os.Error) {
+	return c.beginCommandList("command_list_ok_begin", true)
+}
+
+// beginCommandList locks c.cmdMu for the entire command list, released
+// only by the returned CommandList's End, so that no other command
+// issued on c (e.g. by the keepalive pingLoop, or any other concurrent
+// caller) can land on the wire in the middle of the list's body.
+func (c *Client) beginCommandList(begin string, ok bool) (*CommandList, os.Error) {
+	c.cmdMu.Lock()
+	text, err := c.connText()
+	if err != nil {
+		c.cmdMu.Unlock()
+		return nil, err
+	}
+	id, err := text.Cmd(begin)
+	if err != nil {
+		c.cmdMu.Unlock()
+		return nil, err
+	}
+	return &CommandList{client: c, text: text, id: id, ok: ok}, nil
+}
+
+// Cmd queues an arbitrary command, formatted as with fmt.Sprintf, in the
+// command list.
+func (cl *CommandList) Cmd(format string, args ...interface{}) os.Error {
+	return cl.text.PrintfLine(format, args...)
+}
+
+// Add queues an add command in the command list. See (*Client).Add.
+func (cl *CommandList) Add(uri string) os.Error {
+	return cl.Cmd("add %q", uri)
+}
+
+// AddId queues an addid command in the command list. See (*Client).AddId.
+// Unlike (*Client).AddId, the song id assigned by MPD isn't known until
+// End returns the command list's results.
+func (cl *CommandList) AddId(uri string, pos int) os.Error {
+	if pos >= 0 {
+		return cl.Cmd("addid %q %d", uri, pos)
+	}
+	return cl.Cmd("addid %q", uri)
+}
+
+// Delete queues a delete command in the command list. See (*Client).Delete.
+func (cl *CommandList) Delete(start, end int) os.Error {
+	if end < 0 {
+		return cl.Cmd("delete %d", start)
+	}
+	return cl.Cmd("delete %d %d", start, end)
+}
+
+// Play queues a play command in the command list. See (*Client).Play.
+func (cl *CommandList) Play(pos int) os.Error {
+	if pos < 0 {
+		return cl.Cmd("play")
+	}
+	return cl.Cmd("play %d", pos)
+}
+
+// Clear queues a clear command in the command list. See (*Client).Clear.
+func (cl *CommandList) Clear() os.Error {
+	return cl.Cmd("clear")
+}
+
+// Update queues an update command in the command list. See (*Client).Update.
+func (cl *CommandList) Update(uri string) os.Error {
+	return cl.Cmd("update %q", uri)
+}
+
+// End flushes the command list to MPD and waits for the result. For a
+// plain command list (BeginCommandList), it returns once MPD sends a
+// single terminating OK. For an OK command list (BeginCommandListOK),
+// it returns the attributes reported for each queued command (an empty
+// Attrs for commands, such as add, that don't report any) along with a
+// *CommandListError identifying which command failed, if any.
+func (cl *CommandList) End() ([]Attrs, os.Error) {
+	defer cl.client.cmdMu.Unlock()
+
+	if err := cl.text.PrintfLine("command_list_end"); err != nil {
+		return nil, err
+	}
+	cl.text.StartResponse(cl.id)
+	defer cl.text.EndResponse(cl.id)
+
+	if !cl.ok {
+		return nil, readOKLine(cl.text)
+	}
+
+	results := []Attrs{}
+	index := 0
+	for {
+		line, err := cl.text.ReadLine()
+		if err != nil {
+			return results, err
+		}
+		switch {
+		case line == "OK":
+			return results, nil
+		case line == "list_OK":
+			// list_OK marks the end of the current command's output,
+			// so it always gets a slot in results, even if the
+			// command (e.g. add) never produced an attribute line.
+			for len(results) <= index {
+				results = append(results, Attrs{})
+			}
+			index++
+			continue
+		case strings.HasPrefix(line, "ACK "):
+			return results, &CommandListError{Index: index, Err: parseServerError(line)}
+		}
+		for len(results) <= index {
+			results = append(results, Attrs{})
+		}
+		z := strings.Index(line, ": ")
+		if z < 0 {
+			return results, textproto.ProtocolError("can't parse line: " + line)
+		}
+		results[index][line[0:z]] = line[z+2:]
+	}
+	panic("unreachable")
+}
+
+// CommandListError reports that a command at position Index (0-based)
+// in an OK command list failed.
+type CommandListError struct {
+	Index int
+	Err   os.Error
+}
+
+func (e *CommandListError) String() string {
+	return fmt.Sprintf("mpd: command %d in command list failed: %s", e.Index, e.Err)
+}
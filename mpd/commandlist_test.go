@@ -0,0 +1,126 @@
+// Copyright 2009 The GoMPD Authors. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package mpd
+
+import (
+	"net"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestCommandListOKEndGivesEveryCommandAResultsSlot(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	c := &Client{text: textproto.NewConn(clientConn)}
+
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		server := textproto.NewConn(serverConn)
+		for {
+			line, err := server.ReadLine()
+			if err != nil {
+				return
+			}
+			if line == "command_list_end" {
+				// Two queued commands, both silent (add produces no
+				// attribute lines), so the only output is one list_OK
+				// per command.
+				server.PrintfLine("list_OK")
+				server.PrintfLine("list_OK")
+				server.PrintfLine("OK")
+				return
+			}
+		}
+	}()
+
+	cl, err := c.beginCommandList("command_list_ok_begin", true)
+	if err != nil {
+		t.Fatalf("beginCommandList: %v", err)
+	}
+	if err := cl.Add("a"); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := cl.Add("b"); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	results, err := cl.End()
+	if err != nil {
+		t.Fatalf("End: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("End() returned %d results, want 2 (one per queued command, even though add is silent)", len(results))
+	}
+	<-done
+}
+
+func TestBeginCommandListBlocksConcurrentCommands(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	c := &Client{text: textproto.NewConn(clientConn)}
+
+	// The fake server reads and answers each line as it arrives, in
+	// the order the real protocol requires: command_list_begin, then
+	// (only once End is called) command_list_end, then (only once the
+	// concurrent ping has had a chance to jump the queue, which it
+	// must not) ping.
+	serverLines := make(chan string, 8)
+	go func() {
+		server := textproto.NewConn(serverConn)
+		for {
+			line, err := server.ReadLine()
+			if err != nil {
+				return
+			}
+			serverLines <- line
+			// command_list_begin isn't itself answered; its response
+			// comes bundled with command_list_end's.
+			if line != "command_list_begin" {
+				server.PrintfLine("OK")
+			}
+		}
+	}()
+
+	cl, err := c.beginCommandList("command_list_begin", false)
+	if err != nil {
+		t.Fatalf("beginCommandList: %v", err)
+	}
+	if line := <-serverLines; line != "command_list_begin" {
+		t.Fatalf("server saw %q, want command_list_begin", line)
+	}
+
+	// While the list is open, a concurrent command on the same Client
+	// must block rather than writing straight onto the wire, or it
+	// would get spliced into the list's body.
+	unblocked := make(chan bool, 1)
+	go func() {
+		c.okCmd("ping")
+		unblocked <- true
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case <-unblocked:
+		t.Fatalf("a concurrent command ran while a command list was still open")
+	default:
+	}
+
+	if _, err := cl.End(); err != nil {
+		t.Fatalf("End: %v", err)
+	}
+	if line := <-serverLines; line != "command_list_end" {
+		t.Fatalf("server saw %q, want command_list_end", line)
+	}
+
+	if line := <-serverLines; line != "ping" {
+		t.Fatalf("server saw %q, want ping", line)
+	}
+	<-unblocked
+}
@@ -0,0 +1,163 @@
+// Copyright 2009 The GoMPD Authors. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package mpd
+
+import (
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//
+// Database
+//
+
+// Update updates MPD's database: finds new files, removes deleted
+// files, and updates modified files. If uri is not empty, only the
+// corresponding directory or file is updated. It returns the job id
+// that MPD assigns to the update; use that id to tell apart the
+// "updating_db" status that belongs to this call from a later one.
+func (c *Client) Update(uri string) (jobID int, err os.Error) {
+	return c.updateOrRescan("update", uri)
+}
+
+// Rescan is like Update, but also rescans unmodified files.
+func (c *Client) Rescan(uri string) (jobID int, err os.Error) {
+	return c.updateOrRescan("rescan", uri)
+}
+
+func (c *Client) updateOrRescan(cmd, uri string) (int, os.Error) {
+	var attrs Attrs
+	read := func(text *textproto.Conn) (err os.Error) {
+		attrs, err = readAttrs(text)
+		return
+	}
+
+	var err os.Error
+	if uri != "" {
+		err = c.roundTrip(read, "%s %q", cmd, uri)
+	} else {
+		err = c.roundTrip(read, cmd)
+	}
+	err, reconnected := splitReconnected(err)
+	if err != nil {
+		return -1, err
+	}
+
+	tok, ok := attrs["updating_db"]
+	if !ok {
+		return -1, textproto.ProtocolError("update/rescan did not return updating_db")
+	}
+	jobID, err := strconv.Atoi(tok)
+	if err != nil {
+		return -1, err
+	}
+	return reconnectedInt(jobID, reconnected)
+}
+
+// ListAllInfo returns attributes for songs in the library. If uri is
+// not empty, only the corresponding directory or file is listed.
+func (c *Client) ListAllInfo(uri string) ([]Attrs, os.Error) {
+	var attrs []Attrs
+	err := c.roundTrip(func(text *textproto.Conn) (err os.Error) {
+		attrs, err = readAttrsList(text, "file")
+		return
+	}, "listallinfo %q", uri)
+	err, reconnected := splitReconnected(err)
+	if err != nil {
+		return nil, err
+	}
+	return reconnectedAttrsList(attrs, reconnected)
+}
+
+// LsInfo lists the contents of a directory in the library (songs,
+// directories and stored playlists). If uri is not empty, it names the
+// directory to list; otherwise the root directory is listed.
+func (c *Client) LsInfo(uri string) ([]Attrs, os.Error) {
+	var attrs []Attrs
+	err := c.roundTrip(func(text *textproto.Conn) (err os.Error) {
+		attrs, err = readAttrsList(text, "file", "directory", "playlist")
+		return
+	}, "lsinfo %q", uri)
+	err, reconnected := splitReconnected(err)
+	if err != nil {
+		return nil, err
+	}
+	return reconnectedAttrsList(attrs, reconnected)
+}
+
+// Find searches the library for songs that exactly match the given
+// tag/value pairs (e.g. Find("album", "Foo", "artist", "Bar")).
+func (c *Client) Find(args ...string) ([]Attrs, os.Error) {
+	var attrs []Attrs
+	err := c.roundTrip(func(text *textproto.Conn) (err os.Error) {
+		attrs, err = readAttrsList(text, "file")
+		return
+	}, "find %s", quoteArgs(args))
+	err, reconnected := splitReconnected(err)
+	if err != nil {
+		return nil, err
+	}
+	return reconnectedAttrsList(attrs, reconnected)
+}
+
+// Search is like Find, but searches case-insensitively for partial
+// matches.
+func (c *Client) Search(args ...string) ([]Attrs, os.Error) {
+	var attrs []Attrs
+	err := c.roundTrip(func(text *textproto.Conn) (err os.Error) {
+		attrs, err = readAttrsList(text, "file")
+		return
+	}, "search %s", quoteArgs(args))
+	err, reconnected := splitReconnected(err)
+	if err != nil {
+		return nil, err
+	}
+	return reconnectedAttrsList(attrs, reconnected)
+}
+
+// List returns the unique values of tag, optionally restricted to songs
+// matching the given tag/value filter pairs (as with Find).
+func (c *Client) List(tag string, filter ...string) ([]string, os.Error) {
+	var values []string
+	err := c.roundTrip(func(text *textproto.Conn) os.Error {
+		values = []string{}
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return err
+			}
+			if line == "OK" {
+				break
+			}
+			z := strings.Index(line, ": ")
+			if z < 0 {
+				return textproto.ProtocolError("can't parse line: " + line)
+			}
+			values = append(values, line[z+2:])
+		}
+		return nil
+	}, "list %q %s", tag, quoteArgs(filter))
+	err, reconnected := splitReconnected(err)
+	if err != nil {
+		return nil, err
+	}
+	return reconnectedStrings(values, reconnected)
+}
+
+// quoteArgs quotes each element of args with %q and joins them with
+// spaces, the way Add already quotes a single uri, so that tag values
+// containing spaces or quotes round-trip safely.
+func quoteArgs(args []string) string {
+	quoted := ""
+	for i, arg := range args {
+		if i > 0 {
+			quoted += " "
+		}
+		quoted += strconv.Quote(arg)
+	}
+	return quoted
+}
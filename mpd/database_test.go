@@ -0,0 +1,27 @@
+// Copyright 2009 The GoMPD Authors. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package mpd
+
+import (
+	"testing"
+)
+
+func TestQuoteArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, ""},
+		{[]string{"album"}, `"album"`},
+		{[]string{"artist", "Foo"}, `"artist" "Foo"`},
+		{[]string{"album", `Foo "Bar" Baz`}, `"album" "Foo \"Bar\" Baz"`},
+	}
+	for _, test := range tests {
+		got := quoteArgs(test.args)
+		if got != test.want {
+			t.Errorf("quoteArgs(%v) = %q, want %q", test.args, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2009 The GoMPD Authors. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package mpd
+
+import (
+	"fmt"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MPD's ACK error codes, as documented in the protocol reference.
+const (
+	ErrorNotList       = 1
+	ErrorArg           = 2
+	ErrorPassword      = 3
+	ErrorPermission    = 4
+	ErrorUnknownCmd    = 5
+	ErrorNoExist       = 50
+	ErrorPlaylistMax   = 51
+	ErrorSystem        = 52
+	ErrorPlaylistLoad  = 53
+	ErrorUpdateAlready = 54
+	ErrorPlayerSync    = 55
+	ErrorExist         = 56
+)
+
+// ServerError reports that MPD replied to a command with an ACK error,
+// parsed from a line of the form "ACK [code@index] {command} message".
+type ServerError struct {
+	Code             int
+	CommandListIndex int
+	Command          string
+	Message          string
+}
+
+func (e *ServerError) String() string {
+	return fmt.Sprintf("mpd: ACK [%d@%d] {%s} %s", e.Code, e.CommandListIndex, e.Command, e.Message)
+}
+
+// parseServerError parses an MPD ACK line into a *ServerError. If line
+// doesn't look like an ACK, it returns a generic textproto.ProtocolError
+// instead, as readOKLine always did before ACK parsing was added.
+func parseServerError(line string) os.Error {
+	if !strings.HasPrefix(line, "ACK [") {
+		return textproto.ProtocolError("unexpected response: " + line)
+	}
+	rest := line[len("ACK ["):]
+
+	at := strings.Index(rest, "@")
+	if at < 0 {
+		return textproto.ProtocolError("unexpected response: " + line)
+	}
+	code, err := strconv.Atoi(rest[0:at])
+	if err != nil {
+		return textproto.ProtocolError("unexpected response: " + line)
+	}
+	rest = rest[at+1:]
+
+	bracket := strings.Index(rest, "]")
+	if bracket < 0 {
+		return textproto.ProtocolError("unexpected response: " + line)
+	}
+	index, err := strconv.Atoi(rest[0:bracket])
+	if err != nil {
+		return textproto.ProtocolError("unexpected response: " + line)
+	}
+	rest = strings.TrimLeft(rest[bracket+1:], " ")
+
+	if !strings.HasPrefix(rest, "{") {
+		return textproto.ProtocolError("unexpected response: " + line)
+	}
+	brace := strings.Index(rest, "}")
+	if brace < 0 {
+		return textproto.ProtocolError("unexpected response: " + line)
+	}
+	command := rest[1:brace]
+	message := strings.TrimLeft(rest[brace+1:], " ")
+
+	return &ServerError{Code: code, CommandListIndex: index, Command: command, Message: message}
+}
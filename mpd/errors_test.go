@@ -0,0 +1,60 @@
+// Copyright 2009 The GoMPD Authors. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package mpd
+
+import (
+	"testing"
+)
+
+func TestParseServerError(t *testing.T) {
+	tests := []struct {
+		line string
+		want *ServerError
+	}{
+		{
+			"ACK [2@0] {play} Bad song index",
+			&ServerError{Code: 2, CommandListIndex: 0, Command: "play", Message: "Bad song index"},
+		},
+		{
+			"ACK [50@3] {add} No such directory",
+			&ServerError{Code: 50, CommandListIndex: 3, Command: "add", Message: "No such directory"},
+		},
+		{
+			"ACK [4@0] {} Permission denied",
+			&ServerError{Code: 4, CommandListIndex: 0, Command: "", Message: "Permission denied"},
+		},
+	}
+	for _, test := range tests {
+		err := parseServerError(test.line)
+		se, ok := err.(*ServerError)
+		if !ok {
+			t.Errorf("parseServerError(%q) = %v, want *ServerError", test.line, err)
+			continue
+		}
+		if *se != *test.want {
+			t.Errorf("parseServerError(%q) = %+v, want %+v", test.line, *se, *test.want)
+		}
+	}
+}
+
+func TestParseServerErrorNotACK(t *testing.T) {
+	tests := []string{
+		"",
+		"OK",
+		"ACK malformed",
+		"ACK [notanumber@0] {play} message",
+		"ACK [2@notanumber] {play} message",
+		"ACK [2@0] no opening brace",
+	}
+	for _, line := range tests {
+		err := parseServerError(line)
+		if _, ok := err.(*ServerError); ok {
+			t.Errorf("parseServerError(%q) parsed as *ServerError, want a generic error", line)
+		}
+		if err == nil {
+			t.Errorf("parseServerError(%q) = nil, want an error", line)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright 2009 The GoMPD Authors. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package mpd
+
+import (
+	"net"
+	"net/textproto"
+	"os"
+	"time"
+)
+
+// Backoff describes the exponential backoff used between reconnect
+// attempts: the first retry waits Initial, and each subsequent retry
+// doubles the wait, capped at Max.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// Config carries everything needed to (re)establish a connection to
+// MPD. With only Network and Address set, it describes a plain,
+// unauthenticated connection without keepalive pings, equivalent to what
+// Dial produces. Every Client, however configured, reconnects when a
+// command finds its connection dead (see roundTrip).
+type Config struct {
+	Network  string
+	Address  string
+	Password string
+
+	// ReconnectBackoff controls how DialWithConfig's Client waits
+	// between attempts to reconnect after the connection is lost.
+	ReconnectBackoff Backoff
+
+	// PingInterval, if positive, causes the Client to send ping on
+	// the connection at this interval to keep it alive.
+	PingInterval time.Duration
+
+	// DialTimeout, if positive, bounds how long the initial dial (and
+	// every redial) is allowed to take.
+	DialTimeout time.Duration
+}
+
+// AuthError reports that MPD rejected a "password" command.
+type AuthError struct {
+	Err os.Error
+}
+
+func (e *AuthError) String() string {
+	return "mpd: authentication failed: " + e.Err.String()
+}
+
+// ReconnectedError is returned alongside a successful result to signal
+// that the connection to MPD had to be reestablished in order to
+// complete the request. Callers that cache server-assigned state (e.g.
+// song ids) should treat it as a cue to refresh that state.
+type ReconnectedError struct {
+	Err os.Error // the error that triggered the reconnect
+}
+
+func (e *ReconnectedError) String() string {
+	return "mpd: connection was lost and has been reestablished: " + e.Err.String()
+}
+
+// DialWithConfig connects to MPD as described by cfg. If cfg.Password
+// is not empty, it authenticates the connection. If cfg.PingInterval
+// is positive, a goroutine is started that pings MPD on that interval,
+// sharing the same request pipeline as ordinary commands, to keep the
+// connection from being dropped by a NAT or firewall.
+func DialWithConfig(cfg Config) (*Client, os.Error) {
+	text, err := dialConn(cfg)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{text: text, cfg: cfg}
+	if cfg.PingInterval > 0 {
+		c.pingQuit = make(chan bool)
+		go c.pingLoop()
+	}
+	return c, nil
+}
+
+// dialConn performs the greeting handshake (and password authentication,
+// if configured) over a freshly dialed connection.
+func dialConn(cfg Config) (*textproto.Conn, os.Error) {
+	var text *textproto.Conn
+	if cfg.DialTimeout > 0 {
+		conn, err := net.DialTimeout(cfg.Network, cfg.Address, cfg.DialTimeout)
+		if err != nil {
+			return nil, err
+		}
+		text = textproto.NewConn(conn)
+	} else {
+		var err os.Error
+		text, err = textproto.Dial(cfg.Network, cfg.Address)
+		if err != nil {
+			return nil, err
+		}
+	}
+	line, err := text.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 6 || line[0:6] != "OK MPD" {
+		return nil, textproto.ProtocolError("no greeting")
+	}
+	if cfg.Password != "" {
+		if err := authenticate(text, cfg.Password); err != nil {
+			text.Close()
+			if _, ok := err.(*ServerError); ok {
+				return nil, &AuthError{Err: err}
+			}
+			return nil, err
+		}
+	}
+	return text, nil
+}
+
+func authenticate(text *textproto.Conn, password string) os.Error {
+	id, err := text.Cmd("password %q", password)
+	if err != nil {
+		return err
+	}
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+	line, err := text.ReadLine()
+	if err != nil {
+		return err
+	}
+	if line == "OK" {
+		return nil
+	}
+	return parseServerError(line)
+}
+
+// reconnect drops the current connection, if any, and redials MPD,
+// replaying cfg.Password, with exponential backoff between attempts.
+func (c *Client) reconnect() os.Error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.text != nil {
+		c.text.Close()
+		c.text = nil
+	}
+
+	backoff := c.cfg.ReconnectBackoff.Initial
+	if backoff <= 0 {
+		backoff = 100 * time.Millisecond
+	}
+	max := c.cfg.ReconnectBackoff.Max
+	if max <= 0 {
+		max = 30 * time.Second
+	}
+	for {
+		text, err := dialConn(c.cfg)
+		if err == nil {
+			c.text = text
+			return nil
+		}
+		time.Sleep(int64(backoff))
+		backoff *= 2
+		if backoff > max {
+			backoff = max
+		}
+	}
+	panic("unreachable")
+}
+
+// isConnError reports whether err indicates the underlying connection
+// died, as opposed to MPD rejecting the command.
+func isConnError(err os.Error) bool {
+	if err == os.EOF {
+		return true
+	}
+	if _, ok := err.(*net.OpError); ok {
+		return true
+	}
+	return false
+}
+
+// connText returns the Client's current connection, so that a caller
+// doing its own Cmd/StartResponse/EndResponse sequence (as CommandList
+// does) always starts from a connection that was actually live at the
+// time, rather than a stale *textproto.Conn captured before a redial.
+func (c *Client) connText() (*textproto.Conn, os.Error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.text == nil {
+		return nil, os.NewError("mpd: not connected")
+	}
+	return c.text, nil
+}
+
+// roundTrip issues format as a command and runs read against the same
+// *textproto.Conn the command was issued on, covering the whole
+// Cmd/StartResponse/read/EndResponse sequence. If the connection turns
+// out to have died — whether the write or the read is what surfaces it
+// — it reconnects and retries the entire round trip once. On a retry
+// that succeeds, it returns a *ReconnectedError instead of nil, so
+// read's side effects (e.g. the Attrs it populates) are consistent
+// with a single successful attempt while still flagging that the
+// connection was replaced.
+//
+// roundTrip holds c.cmdMu for its whole span, which is also what a
+// CommandList holds from Begin to End, so a command can never be
+// interleaved into the middle of another goroutine's command list.
+func (c *Client) roundTrip(read func(text *textproto.Conn) os.Error, format string, args ...interface{}) os.Error {
+	c.cmdMu.Lock()
+	defer c.cmdMu.Unlock()
+
+	text, err := c.connText()
+	if err != nil {
+		return err
+	}
+	err = roundTripOnce(text, read, format, args...)
+	if err == nil || !isConnError(err) {
+		return err
+	}
+	triggered := err
+
+	if err := c.reconnect(); err != nil {
+		return err
+	}
+	text, err = c.connText()
+	if err != nil {
+		return err
+	}
+	if err := roundTripOnce(text, read, format, args...); err != nil {
+		return err
+	}
+	return &ReconnectedError{Err: triggered}
+}
+
+func roundTripOnce(text *textproto.Conn, read func(text *textproto.Conn) os.Error, format string, args ...interface{}) os.Error {
+	id, err := text.Cmd(format, args...)
+	if err != nil {
+		return err
+	}
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+	return read(text)
+}
+
+// splitReconnected separates a *ReconnectedError carried in err from a
+// genuine failure: ordinary errors pass through unchanged, while a
+// *ReconnectedError yields a nil error (so callers keep going) plus the
+// ReconnectedError to surface once the command finishes successfully.
+func splitReconnected(err os.Error) (os.Error, os.Error) {
+	if re, ok := err.(*ReconnectedError); ok {
+		return nil, re
+	}
+	return err, nil
+}
+
+func reconnectedAttrs(attrs Attrs, reconnected os.Error) (Attrs, os.Error) {
+	if reconnected != nil {
+		return attrs, reconnected
+	}
+	return attrs, nil
+}
+
+func reconnectedAttrsList(attrs []Attrs, reconnected os.Error) ([]Attrs, os.Error) {
+	if reconnected != nil {
+		return attrs, reconnected
+	}
+	return attrs, nil
+}
+
+func reconnectedStrings(values []string, reconnected os.Error) ([]string, os.Error) {
+	if reconnected != nil {
+		return values, reconnected
+	}
+	return values, nil
+}
+
+func reconnectedInt(n int, reconnected os.Error) (int, os.Error) {
+	if reconnected != nil {
+		return n, reconnected
+	}
+	return n, nil
+}
+
+// pingLoop periodically sends ping to MPD until the Client is closed.
+func (c *Client) pingLoop() {
+	c.mutex.Lock()
+	interval := c.cfg.PingInterval
+	quit := c.pingQuit
+	c.mutex.Unlock()
+	if quit == nil { // Close already ran
+		return
+	}
+
+	ticker := time.NewTicker(int64(interval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.Ping()
+		case <-quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2009 The GoMPD Authors. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package mpd
+
+import (
+	"net"
+	"net/textproto"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestIsConnError(t *testing.T) {
+	tests := []struct {
+		err  os.Error
+		want bool
+	}{
+		{os.EOF, true},
+		{&net.OpError{Op: "read", Error: os.NewError("broken pipe")}, true},
+		{textproto.ProtocolError("unexpected response: nope"), false},
+		{nil, false},
+	}
+	for _, test := range tests {
+		if got := isConnError(test.err); got != test.want {
+			t.Errorf("isConnError(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+func TestSplitReconnected(t *testing.T) {
+	plain := os.NewError("boom")
+	if err, reconnected := splitReconnected(plain); err != plain || reconnected != nil {
+		t.Errorf("splitReconnected(%v) = (%v, %v), want (%v, nil)", plain, err, reconnected, plain)
+	}
+
+	re := &ReconnectedError{Err: plain}
+	if err, reconnected := splitReconnected(re); err != nil || reconnected != re {
+		t.Errorf("splitReconnected(%v) = (%v, %v), want (nil, %v)", re, err, reconnected, re)
+	}
+}
+
+// greet writes the MPD connection banner that dialConn expects as the
+// first line of any connection, real or faked.
+func greet(conn net.Conn) *textproto.Conn {
+	text := textproto.NewConn(conn)
+	text.PrintfLine("OK MPD 0.20.0")
+	return text
+}
+
+// TestRoundTripRetriesFullRoundTripOnReconnect exercises the scenario
+// this request exists for: the dead connection surfaces on the *read*
+// of a command's response, not the write of the command itself. It
+// dials against a real TCP listener so the retry goes through an
+// actual redial, not a faked one.
+func TestRoundTripRetriesFullRoundTripOnReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		// First connection: greet, read the "status" command, then
+		// drop the connection without answering it, so the failure
+		// surfaces as a read error, not a write error.
+		conn1, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		text1 := greet(conn1)
+		text1.ReadLine()
+		conn1.Close()
+
+		// Second connection: the reconnect. Greet again and this time
+		// actually answer the retried "status".
+		conn2, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		text2 := greet(conn2)
+		text2.ReadLine()
+		text2.PrintfLine("volume: 50")
+		text2.PrintfLine("OK")
+	}()
+
+	c, err := Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close()
+
+	done := make(chan bool, 1)
+	var attrs Attrs
+	var statusErr os.Error
+	go func() {
+		attrs, statusErr = c.Status()
+		done <- true
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Status did not return; the retry likely never covered the read side")
+	}
+
+	if statusErr == nil {
+		t.Fatalf("Status() err = nil, want a *ReconnectedError signaling the retry")
+	}
+	if _, ok := statusErr.(*ReconnectedError); !ok {
+		t.Fatalf("Status() err = %v (%T), want *ReconnectedError", statusErr, statusErr)
+	}
+	if attrs["volume"] != "50" {
+		t.Errorf("Status()[\"volume\"] = %q, want %q", attrs["volume"], "50")
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2009 The GoMPD Authors. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package mpd
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// State is the MPD playback state, as reported in a Status.
+type State string
+
+const (
+	StatePlay  State = "play"
+	StatePause State = "pause"
+	StateStop  State = "stop"
+)
+
+// AudioFormat describes the sample rate, bit depth and channel count of
+// the audio currently being played, as reported in a Status.
+type AudioFormat struct {
+	SampleRate int
+	Bits       int // 0 if the format uses floating point samples
+	Channels   int
+}
+
+// Status is a typed view of the attributes returned by (*Client).Status,
+// saving callers from having to parse Attrs themselves.
+type Status struct {
+	State          State
+	Volume         int // 0-100, or -1 if unknown
+	Repeat         bool
+	Random         bool
+	Single         bool
+	Consume        bool
+	Playlist       int
+	PlaylistLength int
+	Song           int // playlist position of the current song, -1 if none
+	SongID         int // -1 if none
+	NextSong       int
+	NextSongID     int
+	Elapsed        time.Duration
+	Duration       time.Duration
+	Bitrate        int
+	Audio          AudioFormat
+	Error          string
+}
+
+// StatusTyped is like Status, but returns a typed *Status instead of
+// raw Attrs.
+func (c *Client) StatusTyped() (*Status, os.Error) {
+	attrs, err := c.Status()
+	err, reconnected := splitReconnected(err)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Status{
+		Volume:     -1,
+		Song:       -1,
+		SongID:     -1,
+		NextSong:   -1,
+		NextSongID: -1,
+	}
+	s.State = State(attrs["state"])
+	if n, aerr := strconv.Atoi(attrs["volume"]); aerr == nil {
+		s.Volume = n
+	}
+	s.Repeat = attrs["repeat"] == "1"
+	s.Random = attrs["random"] == "1"
+	s.Single = attrs["single"] == "1"
+	s.Consume = attrs["consume"] == "1"
+	if n, aerr := strconv.Atoi(attrs["playlist"]); aerr == nil {
+		s.Playlist = n
+	}
+	if n, aerr := strconv.Atoi(attrs["playlistlength"]); aerr == nil {
+		s.PlaylistLength = n
+	}
+	if n, aerr := strconv.Atoi(attrs["song"]); aerr == nil {
+		s.Song = n
+	}
+	if n, aerr := strconv.Atoi(attrs["songid"]); aerr == nil {
+		s.SongID = n
+	}
+	if n, aerr := strconv.Atoi(attrs["nextsong"]); aerr == nil {
+		s.NextSong = n
+	}
+	if n, aerr := strconv.Atoi(attrs["nextsongid"]); aerr == nil {
+		s.NextSongID = n
+	}
+	if n, aerr := strconv.Atoi(attrs["bitrate"]); aerr == nil {
+		s.Bitrate = n
+	}
+	if elapsed, duration, ok := parseElapsedDuration(attrs); ok {
+		s.Elapsed = elapsed
+		s.Duration = duration
+	}
+	if audio, ok := parseAudioFormat(attrs["audio"]); ok {
+		s.Audio = audio
+	}
+	s.Error = attrs["error"]
+
+	if reconnected != nil {
+		return s, reconnected
+	}
+	return s, nil
+}
+
+// Song is a typed view of the attributes returned by
+// (*Client).CurrentSong, saving callers from having to parse Attrs
+// themselves.
+type Song struct {
+	File        string
+	Id          int // -1 if unknown
+	Pos         int // position in the current playlist, -1 if unknown
+	Duration    time.Duration
+	Artist      string
+	AlbumArtist string
+	Album       string
+	Title       string
+	Track       string
+	Date        string
+	Genre       string
+}
+
+// CurrentSongTyped is like CurrentSong, but returns a typed *Song
+// instead of raw Attrs.
+func (c *Client) CurrentSongTyped() (*Song, os.Error) {
+	attrs, err := c.CurrentSong()
+	err, reconnected := splitReconnected(err)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Song{
+		File:        attrs["file"],
+		Id:          -1,
+		Pos:         -1,
+		Artist:      attrs["Artist"],
+		AlbumArtist: attrs["AlbumArtist"],
+		Album:       attrs["Album"],
+		Title:       attrs["Title"],
+		Track:       attrs["Track"],
+		Date:        attrs["Date"],
+		Genre:       attrs["Genre"],
+	}
+	if n, aerr := strconv.Atoi(attrs["Id"]); aerr == nil {
+		s.Id = n
+	}
+	if n, aerr := strconv.Atoi(attrs["Pos"]); aerr == nil {
+		s.Pos = n
+	}
+	if d, ok := parseSeconds(attrs["Time"]); ok {
+		s.Duration = d
+	}
+	if d, ok := parseSeconds(attrs["duration"]); ok {
+		s.Duration = d
+	}
+
+	if reconnected != nil {
+		return s, reconnected
+	}
+	return s, nil
+}
+
+// parseSeconds parses a decimal number of seconds, as MPD reports
+// elapsed/duration, into a time.Duration.
+func parseSeconds(s string) (time.Duration, bool) {
+	f, err := strconv.Atof64(s)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(f * float64(time.Second)), true
+}
+
+// parseElapsedDuration reads a song's elapsed playback time and total
+// duration out of a Status's attributes, preferring the newer
+// "elapsed"/"duration" pair over the older combined "time: elapsed:total".
+func parseElapsedDuration(attrs Attrs) (elapsed, duration time.Duration, ok bool) {
+	if e, eok := attrs["elapsed"]; eok {
+		if d, dok := attrs["duration"]; dok {
+			if ed, ok1 := parseSeconds(e); ok1 {
+				if dd, ok2 := parseSeconds(d); ok2 {
+					return ed, dd, true
+				}
+			}
+		}
+	}
+	if t, tok := attrs["time"]; tok {
+		parts := strings.SplitN(t, ":", 2)
+		if len(parts) == 2 {
+			e, err1 := strconv.Atoi(parts[0])
+			d, err2 := strconv.Atoi(parts[1])
+			if err1 == nil && err2 == nil {
+				return time.Duration(e) * time.Second, time.Duration(d) * time.Second, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// parseAudioFormat parses MPD's "rate:bits:channels" audio format
+// string, e.g. "44100:16:2" or "48000:f:2" for floating point samples.
+func parseAudioFormat(s string) (AudioFormat, bool) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) != 3 {
+		return AudioFormat{}, false
+	}
+	rate, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return AudioFormat{}, false
+	}
+	channels, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return AudioFormat{}, false
+	}
+	bits, _ := strconv.Atoi(parts[1]) // left at 0 for "f" (floating point)
+	return AudioFormat{SampleRate: rate, Bits: bits, Channels: channels}, true
+}
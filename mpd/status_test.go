@@ -0,0 +1,80 @@
+// Copyright 2009 The GoMPD Authors. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package mpd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAudioFormat(t *testing.T) {
+	tests := []struct {
+		s    string
+		want AudioFormat
+		ok   bool
+	}{
+		{"44100:16:2", AudioFormat{SampleRate: 44100, Bits: 16, Channels: 2}, true},
+		{"48000:f:2", AudioFormat{SampleRate: 48000, Bits: 0, Channels: 2}, true},
+		{"", AudioFormat{}, false},
+		{"44100:16", AudioFormat{}, false},
+		{"notanumber:16:2", AudioFormat{}, false},
+		{"44100:16:notanumber", AudioFormat{}, false},
+	}
+	for _, test := range tests {
+		got, ok := parseAudioFormat(test.s)
+		if ok != test.ok {
+			t.Errorf("parseAudioFormat(%q) ok = %v, want %v", test.s, ok, test.ok)
+			continue
+		}
+		if ok && got != test.want {
+			t.Errorf("parseAudioFormat(%q) = %+v, want %+v", test.s, got, test.want)
+		}
+	}
+}
+
+func TestParseElapsedDuration(t *testing.T) {
+	tests := []struct {
+		attrs        Attrs
+		elapsed, dur time.Duration
+		ok           bool
+	}{
+		{
+			Attrs{"elapsed": "1.5", "duration": "180.0"},
+			1500 * time.Millisecond, 180 * time.Second,
+			true,
+		},
+		{
+			Attrs{"time": "10:200"},
+			10 * time.Second, 200 * time.Second,
+			true,
+		},
+		{
+			// elapsed/duration take precedence over the older time attribute.
+			Attrs{"elapsed": "1.5", "duration": "180.0", "time": "99:99"},
+			1500 * time.Millisecond, 180 * time.Second,
+			true,
+		},
+		{
+			Attrs{},
+			0, 0,
+			false,
+		},
+		{
+			Attrs{"time": "malformed"},
+			0, 0,
+			false,
+		},
+	}
+	for _, test := range tests {
+		elapsed, dur, ok := parseElapsedDuration(test.attrs)
+		if ok != test.ok {
+			t.Errorf("parseElapsedDuration(%v) ok = %v, want %v", test.attrs, ok, test.ok)
+			continue
+		}
+		if ok && (elapsed != test.elapsed || dur != test.dur) {
+			t.Errorf("parseElapsedDuration(%v) = (%v, %v), want (%v, %v)", test.attrs, elapsed, dur, test.elapsed, test.dur)
+		}
+	}
+}
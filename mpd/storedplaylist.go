@@ -0,0 +1,96 @@
+// Copyright 2009 The GoMPD Authors. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package mpd
+
+import (
+	"net/textproto"
+	"os"
+)
+
+//
+// Stored playlists
+//
+// MPD distinguishes the current queue (see the playlist related
+// functions in client.go) from named stored playlists, which persist
+// across restarts and are manipulated with the functions below.
+//
+
+// ListPlaylists returns the names and last modification times of all
+// stored playlists, as "playlist" and "Last-Modified" attributes.
+func (c *Client) ListPlaylists() ([]Attrs, os.Error) {
+	var attrs []Attrs
+	err := c.roundTrip(func(text *textproto.Conn) (err os.Error) {
+		attrs, err = readAttrsList(text, "playlist")
+		return
+	}, "listplaylists")
+	err, reconnected := splitReconnected(err)
+	if err != nil {
+		return nil, err
+	}
+	return reconnectedAttrsList(attrs, reconnected)
+}
+
+// PlaylistContents returns attributes for the songs in the named stored
+// playlist.
+func (c *Client) PlaylistContents(name string) ([]Attrs, os.Error) {
+	var attrs []Attrs
+	err := c.roundTrip(func(text *textproto.Conn) (err os.Error) {
+		attrs, err = readAttrsList(text, "file")
+		return
+	}, "listplaylistinfo %q", name)
+	err, reconnected := splitReconnected(err)
+	if err != nil {
+		return nil, err
+	}
+	return reconnectedAttrsList(attrs, reconnected)
+}
+
+// PlaylistLoad loads the named stored playlist into the current queue.
+// If both start and end are positive, only songs at positions in range
+// [start, end) are loaded; otherwise the whole playlist is loaded.
+func (c *Client) PlaylistLoad(name string, start, end int) os.Error {
+	if start >= 0 && end >= 0 {
+		return c.okCmd("load %q %d:%d", name, start, end)
+	}
+	return c.okCmd("load %q", name)
+}
+
+// PlaylistAdd adds the file/directory uri to the named stored playlist.
+func (c *Client) PlaylistAdd(name, uri string) os.Error {
+	return c.okCmd("playlistadd %q %q", name, uri)
+}
+
+// PlaylistClear clears the named stored playlist.
+func (c *Client) PlaylistClear(name string) os.Error {
+	return c.okCmd("playlistclear %q", name)
+}
+
+// PlaylistDelete deletes the song at position pos from the named stored
+// playlist.
+func (c *Client) PlaylistDelete(name string, pos int) os.Error {
+	return c.okCmd("playlistdelete %q %d", name, pos)
+}
+
+// PlaylistMove moves the song at position from to position to in the
+// named stored playlist.
+func (c *Client) PlaylistMove(name string, from, to int) os.Error {
+	return c.okCmd("playlistmove %q %d %d", name, from, to)
+}
+
+// PlaylistRename renames the stored playlist name to newName.
+func (c *Client) PlaylistRename(name, newName string) os.Error {
+	return c.okCmd("rename %q %q", name, newName)
+}
+
+// PlaylistRemove removes the named stored playlist from the playlist
+// directory.
+func (c *Client) PlaylistRemove(name string) os.Error {
+	return c.okCmd("rm %q", name)
+}
+
+// PlaylistSave saves the current queue as a stored playlist named name.
+func (c *Client) PlaylistSave(name string) os.Error {
+	return c.okCmd("save %q", name)
+}
@@ -0,0 +1,164 @@
+// Copyright 2009 The GoMPD Authors. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package mpd
+
+import (
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Watcher represents a connection to MPD that delivers subsystem change
+// events on the Event channel as they happen, using MPD's idle command.
+// A Watcher keeps its own connection to MPD, separate from that of any
+// Client, so that a Client running on another goroutine remains free to
+// issue commands while a Watcher is active.
+type Watcher struct {
+	Event <-chan string
+	Error <-chan os.Error
+
+	client *Client
+
+	// mutex guards subsystems and closed, and is held across each
+	// check-then-write of the idle/noidle command itself (by worker,
+	// Subsystems and Close) so that updating subsystems and
+	// interrupting the pending idle with noidle is atomic with respect
+	// to the worker reading the old subsystems list and reissuing idle.
+	mutex      sync.Mutex
+	subsystems []string
+	closed     bool
+	quit       chan struct{}
+	event      chan string
+	error      chan os.Error
+}
+
+// NewWatcher connects to MPD listening on address addr (e.g.
+// "127.0.0.1:6600") on network net (e.g. "tcp") and starts watching for
+// changes to the given subsystems (e.g. "player", "playlist", "mixer",
+// "options", "database", "update", "stored_playlist", "output",
+// "sticker", "subscription", "message"). If no subsystems are given,
+// changes to any subsystem are reported. If passwd is not empty, it's
+// sent to MPD to authenticate the connection.
+func NewWatcher(net, addr, passwd string, subsystems ...string) (*Watcher, os.Error) {
+	client, err := Dial(net, addr)
+	if err != nil {
+		return nil, err
+	}
+	if passwd != "" {
+		if err := client.okCmd("password %q", passwd); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	w := &Watcher{
+		client:     client,
+		subsystems: subsystems,
+		quit:       make(chan struct{}),
+		event:      make(chan string),
+		error:      make(chan os.Error, 1),
+	}
+	w.Event = w.event
+	w.Error = w.error
+	go w.worker()
+	return w, nil
+}
+
+// worker repeatedly issues idle, waits for MPD's response, and sends
+// every changed subsystem name on w.event, until the Watcher is closed.
+// Every exit path, including an I/O or protocol error, closes the
+// underlying connection so the worker never leaks it.
+func (w *Watcher) worker() {
+	defer close(w.event)
+	for {
+		w.mutex.Lock()
+		if w.closed {
+			w.mutex.Unlock()
+			w.client.Close()
+			return
+		}
+		cmd := "idle"
+		if len(w.subsystems) > 0 {
+			cmd = "idle " + strings.Join(w.subsystems, " ")
+		}
+		err := w.client.text.PrintfLine(cmd)
+		w.mutex.Unlock()
+		if err != nil {
+			w.sendError(err)
+			w.client.Close()
+			return
+		}
+
+		changed := []string{}
+		for {
+			line, err := w.client.text.ReadLine()
+			if err != nil {
+				w.sendError(err)
+				w.client.Close()
+				return
+			}
+			if line == "OK" {
+				break
+			}
+			if !strings.HasPrefix(line, "changed: ") {
+				w.sendError(textproto.ProtocolError("unexpected response: " + line))
+				w.client.Close()
+				return
+			}
+			changed = append(changed, line[len("changed: "):])
+		}
+
+		for _, name := range changed {
+			select {
+			case w.event <- name:
+			case <-w.quit:
+				w.client.Close()
+				return
+			}
+		}
+	}
+}
+
+func (w *Watcher) sendError(err os.Error) {
+	select {
+	case w.error <- err:
+	default:
+	}
+}
+
+// Subsystems changes the set of subsystems the Watcher reports changes
+// for, without reconnecting. An empty list reports changes in any
+// subsystem. The currently pending idle command is interrupted so the
+// new set takes effect immediately. subsystems is updated and noidle is
+// sent while holding mutex, so the worker can never read the old
+// subsystems list and reissue idle with it after this call returns.
+func (w *Watcher) Subsystems(subsystems []string) os.Error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return os.NewError("mpd: watcher is closed")
+	}
+	w.subsystems = subsystems
+	return w.client.text.PrintfLine("noidle")
+}
+
+// Close stops watching. It sends noidle over the wire so any pending
+// idle command returns immediately, and closes w.quit so the worker
+// goroutine unwinds even if it's currently blocked handing a changed
+// subsystem name to a consumer that has stopped reading Event. The
+// worker goroutine closes the Watcher's connection to MPD as it unwinds;
+// Close returns without waiting for that.
+func (w *Watcher) Close() os.Error {
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return nil
+	}
+	w.closed = true
+	err := w.client.text.PrintfLine("noidle")
+	w.mutex.Unlock()
+	close(w.quit)
+	return err
+}
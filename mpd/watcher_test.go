@@ -0,0 +1,109 @@
+// Copyright 2009 The GoMPD Authors. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package mpd
+
+import (
+	"net"
+	"net/textproto"
+	"os"
+	"testing"
+	"time"
+)
+
+func newTestWatcher(clientConn net.Conn) *Watcher {
+	w := &Watcher{
+		client: &Client{text: textproto.NewConn(clientConn)},
+		quit:   make(chan struct{}),
+		event:  make(chan string),
+		error:  make(chan os.Error, 1),
+	}
+	w.Event = w.event
+	w.Error = w.error
+	return w
+}
+
+func TestWatcherDeliversChangedEventThenClosesOnClose(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	go func() {
+		server := textproto.NewConn(serverConn)
+		first := true
+		idling := false
+		for {
+			line, err := server.ReadLine()
+			if err != nil {
+				return
+			}
+			// Like MPD: answer the first idle with a change, hold later
+			// idles open until noidle, and send nothing back for a noidle
+			// with no idle pending or for close.
+			switch {
+			case line == "idle" && first:
+				server.PrintfLine("changed: playlist")
+				server.PrintfLine("OK")
+				first = false
+			case line == "idle":
+				idling = true
+			case line == "noidle" && idling:
+				server.PrintfLine("OK")
+				idling = false
+			}
+		}
+	}()
+
+	w := newTestWatcher(clientConn)
+	go w.worker()
+
+	select {
+	case name := <-w.Event:
+		if name != "playlist" {
+			t.Errorf("got event %q, want %q", name, "playlist")
+		}
+	case err := <-w.Error:
+		t.Fatalf("worker reported an error: %v", err)
+	case <-time.After(2 * time.Second):
+		t.Fatalf("worker never delivered the changed event")
+	}
+
+	if err := w.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if _, ok := <-w.Event; ok {
+		t.Errorf("Event channel should be closed once the worker has unwound")
+	}
+}
+
+func TestWatcherClosesConnectionOnIOError(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	// Closing the server side immediately makes the worker's next read
+	// or write against clientConn fail right away.
+	serverConn.Close()
+
+	w := newTestWatcher(clientConn)
+	go w.worker()
+
+	select {
+	case err := <-w.Error:
+		if err == nil {
+			t.Errorf("worker reported a nil error")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("worker did not report the connection error")
+	}
+
+	if _, ok := <-w.Event; ok {
+		t.Errorf("Event channel should be closed once the worker has unwound")
+	}
+
+	w.client.mutex.Lock()
+	leaked := w.client.text != nil
+	w.client.mutex.Unlock()
+	if leaked {
+		t.Errorf("worker left the connection open after an I/O error")
+	}
+}